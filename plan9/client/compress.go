@@ -0,0 +1,46 @@
+package client
+
+import (
+	"compress/flate"
+	"io"
+)
+
+// Compress returns a ReadWriteCloser that compresses data written to rwc
+// and decompresses data read from it using flate.
+// Each Write is flushed before it returns, so a message written by
+// plan9.WriteFcall reaches the other side without waiting for more data.
+// 9P has no way to negotiate compression, so both ends of the
+// connection must be wrapped, as in NewConn(Compress(c)).
+func Compress(rwc io.ReadWriteCloser) io.ReadWriteCloser {
+	w, _ := flate.NewWriter(rwc, flate.DefaultCompression)
+	return &flateConn{
+		rwc: rwc,
+		r:   flate.NewReader(rwc),
+		w:   w,
+	}
+}
+
+type flateConn struct {
+	rwc io.ReadWriteCloser
+	r   io.ReadCloser
+	w   *flate.Writer
+}
+
+func (c *flateConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
+
+func (c *flateConn) Write(b []byte) (int, error) {
+	n, err := c.w.Write(b)
+	if err != nil {
+		return n, err
+	}
+	return n, c.w.Flush()
+}
+
+// Close closes the underlying connection.
+// It does not write a final flate block: every Write has already
+// been flushed, and the peer may no longer be reading.
+func (c *flateConn) Close() error {
+	return c.rwc.Close()
+}
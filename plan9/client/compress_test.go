@@ -0,0 +1,56 @@
+package client
+
+import (
+	"net"
+	"testing"
+
+	"9fans.net/go/plan9"
+)
+
+func TestCompress(t *testing.T) {
+	c0, c1 := net.Pipe()
+	srv := Compress(c1)
+	errc := make(chan error, 1)
+	go func() {
+		tx, err := plan9.ReadFcall(srv)
+		if err != nil {
+			errc <- err
+			return
+		}
+		if tx.Type != plan9.Tversion {
+			errc <- plan9.ProtocolError("expected Tversion")
+			return
+		}
+		rx := &plan9.Fcall{Type: plan9.Rversion, Tag: tx.Tag, Msize: 8192, Version: tx.Version}
+		if err := plan9.WriteFcall(srv, rx); err != nil {
+			errc <- err
+			return
+		}
+		tx, err = plan9.ReadFcall(srv)
+		if err != nil {
+			errc <- err
+			return
+		}
+		rx = &plan9.Fcall{Type: plan9.Rread, Tag: tx.Tag, Data: []byte("hello, world")}
+		errc <- plan9.WriteFcall(srv, rx)
+	}()
+
+	c, err := NewConn(Compress(c0))
+	if err != nil {
+		t.Fatalf("NewConn: %v", err)
+	}
+	defer c.Close()
+	if c.msize != 8192 {
+		t.Fatalf("msize = %d, want 8192", c.msize)
+	}
+	rx, err := c.rpc(&plan9.Fcall{Type: plan9.Tread, Fid: 1, Count: 100})
+	if err != nil {
+		t.Fatalf("rpc: %v", err)
+	}
+	if string(rx.Data) != "hello, world" {
+		t.Fatalf("read %q, want %q", rx.Data, "hello, world")
+	}
+	if err := <-errc; err != nil {
+		t.Fatalf("server: %v", err)
+	}
+}
@@ -3,6 +3,8 @@ package client // import "9fans.net/go/plan9/client"
 import (
 	"fmt"
 	"io"
+	"os"
+	"strings"
 	"sync"
 
 	"9fans.net/go/plan9"
@@ -12,6 +14,47 @@ type Error string
 
 func (e Error) Error() string { return string(e) }
 
+// A WalkError is returned when walking to a file fails,
+// either because the server rejected the walk or because
+// it stopped short of the final path element.
+// A WalkError satisfies errors.Is(err, os.ErrNotExist) when the walk
+// stopped short or the server said the file does not exist, which lets
+// callers of Fsys.Open tell a missing file from a failed open.
+// Other failures, such as permission denied or a broken connection,
+// do not satisfy it.
+type WalkError struct {
+	Name string // path being walked
+	Err  error  // error from the server or connection, or a short walk
+}
+
+func (e *WalkError) Error() string { return e.Err.Error() }
+
+func (e *WalkError) Unwrap() error { return e.Err }
+
+func (e *WalkError) Is(target error) bool {
+	if target != os.ErrNotExist {
+		return false
+	}
+	s, ok := e.Err.(Error)
+	if !ok {
+		return false
+	}
+	for _, msg := range notExistErrors {
+		if strings.Contains(string(s), msg) {
+			return true
+		}
+	}
+	return false
+}
+
+// notExistErrors lists the error strings servers commonly use
+// to say that a file does not exist.
+var notExistErrors = []string{
+	"does not exist",
+	"not found",
+	"no such file",
+}
+
 type Conn struct {
 	rwc     io.ReadWriteCloser
 	err     error
@@ -52,7 +95,7 @@ func NewConn(rwc io.ReadWriteCloser) (*Conn, error) {
 		return nil, plan9.ProtocolError(fmt.Sprintf("invalid type/tag in Tversion exchange: %v %v", rx.Type, rx.Tag))
 	}
 
-	if rx.Msize > c.msize {
+	if rx.Msize > c.msize || rx.Msize <= plan9.IOHDRSZ {
 		return nil, plan9.ProtocolError(fmt.Sprintf("invalid msize %d in Rversion", rx.Msize))
 	}
 	c.msize = rx.Msize
@@ -62,6 +105,12 @@ func NewConn(rwc io.ReadWriteCloser) (*Conn, error) {
 	return c, nil
 }
 
+// ioUnit returns the largest Tread count or Twrite payload
+// that fits in a message of the negotiated msize.
+func (c *Conn) ioUnit() uint32 {
+	return c.msize - plan9.IOHDRSZ
+}
+
 func (c *Conn) newfid() (*Fid, error) {
 	c.x.Lock()
 	defer c.x.Unlock()
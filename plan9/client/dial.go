@@ -16,6 +16,8 @@ func Dial(network, addr string) (*Conn, error) {
 	return NewConn(c)
 }
 
+// DialService connects to the named service, which is served
+// on a Unix domain socket in the name space directory returned by Namespace.
 func DialService(service string) (*Conn, error) {
 	ns := Namespace()
 	return Dial("unix", ns+"/"+service)
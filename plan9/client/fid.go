@@ -16,7 +16,10 @@ type Fid struct {
 	qid    plan9.Qid
 	fid    uint32
 	mode   uint8
+	iounit uint32
 	offset int64
+	dirbuf []*plan9.Dir // entries read by ReadDir but not yet returned
+	rdbuf  []byte       // Dirread buffer, reused across calls
 	f      sync.Mutex
 }
 
@@ -38,16 +41,36 @@ func (fid *Fid) Create(name string, mode uint8, perm plan9.Perm) error {
 	}
 	fid.mode = mode
 	fid.qid = rx.Qid
+	fid.iounit = rx.Iounit
 	return nil
 }
 
 func (fid *Fid) Dirread() ([]*plan9.Dir, error) {
-	buf := make([]byte, plan9.STATMAX)
+	// A single read never returns more than ioUnit bytes,
+	// so there is no point allocating STATMAX every time.
+	// dirUnpack copies everything out of the buffer,
+	// so it can be kept for the next call. A concurrent
+	// call finds no buffer on the fid and allocates its own.
+	size := int(fid.ioUnit())
+	if size > plan9.STATMAX {
+		size = plan9.STATMAX
+	}
+	fid.f.Lock()
+	buf := fid.rdbuf
+	fid.rdbuf = nil
+	fid.f.Unlock()
+	if len(buf) != size {
+		buf = make([]byte, size)
+	}
 	n, err := fid.Read(buf)
-	if err != nil {
-		return nil, err
+	var dirs []*plan9.Dir
+	if err == nil {
+		dirs, err = dirUnpack(buf[0:n])
 	}
-	return dirUnpack(buf[0:n])
+	fid.f.Lock()
+	fid.rdbuf = buf
+	fid.f.Unlock()
+	return dirs, err
 }
 
 func (fid *Fid) Dirreadall() ([]*plan9.Dir, error) {
@@ -68,6 +91,38 @@ func (fid *Fid) Dirreadall() ([]*plan9.Dir, error) {
 	}
 }
 
+// ReadDir reads the directory fid and returns up to n entries,
+// in the manner of os.File.ReadDir.
+// If n > 0, ReadDir returns at most n entries, reading more from the
+// server only as needed; at the end of the directory it returns
+// no entries and io.EOF.
+// If n <= 0, ReadDir returns all remaining entries and a nil error.
+// Entries read from the server but not yet returned are kept
+// for the next call; any successful Seek discards them.
+func (fid *Fid) ReadDir(n int) ([]*plan9.Dir, error) {
+	fid.f.Lock()
+	dirs := fid.dirbuf
+	fid.dirbuf = nil
+	fid.f.Unlock()
+
+	var err error
+	for err == nil && (n <= 0 || len(dirs) < n) {
+		var d []*plan9.Dir
+		d, err = fid.Dirread()
+		dirs = append(dirs, d...)
+	}
+	if n > 0 && len(dirs) > n {
+		fid.f.Lock()
+		fid.dirbuf = dirs[n:]
+		fid.f.Unlock()
+		dirs = dirs[:n:n]
+	}
+	if err == io.EOF && (n <= 0 || len(dirs) > 0) {
+		err = nil
+	}
+	return dirs, err
+}
+
 func dirUnpack(b []byte) ([]*plan9.Dir, error) {
 	var err error
 	dirs := make([]*plan9.Dir, 0, 10)
@@ -101,14 +156,26 @@ func dirUnpack(b []byte) ([]*plan9.Dir, error) {
 
 func (fid *Fid) Open(mode uint8) error {
 	tx := &plan9.Fcall{Type: plan9.Topen, Fid: fid.fid, Mode: mode}
-	_, err := fid.c.rpc(tx)
+	rx, err := fid.c.rpc(tx)
 	if err != nil {
 		return err
 	}
 	fid.mode = mode
+	fid.iounit = rx.Iounit
 	return nil
 }
 
+// ioUnit returns the maximum number of bytes to transfer
+// in a single Tread or Twrite on fid: the iounit from Ropen or Rcreate
+// if the server gave one, but never more than fits in a message.
+func (fid *Fid) ioUnit() uint32 {
+	max := fid.c.ioUnit()
+	if fid.iounit != 0 && fid.iounit < max {
+		return fid.iounit
+	}
+	return max
+}
+
 func (fid *Fid) Qid() plan9.Qid {
 	return fid.qid
 }
@@ -133,10 +200,9 @@ func (fid *Fid) ReadAt(b []byte, offset int64) (n int, err error) {
 }
 
 func (fid *Fid) readAt(b []byte, offset int64) (n int, err error) {
-	msize := fid.c.msize - plan9.IOHDRSZ
 	n = len(b)
-	if uint32(n) > msize {
-		n = int(msize)
+	if iounit := fid.ioUnit(); uint32(n) > iounit {
+		n = int(iounit)
 	}
 	o := offset
 	if o == -1 {
@@ -177,6 +243,7 @@ func (fid *Fid) Seek(n int64, whence int) (int64, error) {
 	case 0:
 		fid.f.Lock()
 		fid.offset = n
+		fid.dirbuf = nil
 		fid.f.Unlock()
 
 	case 1:
@@ -187,6 +254,7 @@ func (fid *Fid) Seek(n int64, whence int) (int64, error) {
 			return 0, Error("negative offset")
 		}
 		fid.offset = n
+		fid.dirbuf = nil
 		fid.f.Unlock()
 
 	case 2:
@@ -200,6 +268,7 @@ func (fid *Fid) Seek(n int64, whence int) (int64, error) {
 		}
 		fid.f.Lock()
 		fid.offset = n
+		fid.dirbuf = nil
 		fid.f.Unlock()
 
 	default:
@@ -249,9 +318,10 @@ func (fid *Fid) Walk(name string) (*Fid, error) {
 		}
 		rx, err := fid.c.rpc(tx)
 		if err == nil && len(rx.Wqid) != n {
-			err = Error("file '" + name + "' not found")
+			err = Error("'" + name + "' file does not exist")
 		}
 		if err != nil {
+			err = &WalkError{Name: name, Err: err}
 			if nwalk > 0 {
 				wfid.Close()
 			} else {
@@ -277,14 +347,14 @@ func (fid *Fid) Write(b []byte) (n int, err error) {
 }
 
 func (fid *Fid) WriteAt(b []byte, offset int64) (n int, err error) {
-	msize := fid.c.msize - plan9.IOHDRSIZE
+	iounit := fid.ioUnit()
 	tot := 0
 	n = len(b)
 	first := true
 	for tot < n || first {
 		want := n - tot
-		if uint32(want) > msize {
-			want = int(msize)
+		if uint32(want) > iounit {
+			want = int(iounit)
 		}
 		got, err := fid.writeAt(b[tot:tot+want], offset)
 		tot += got
@@ -0,0 +1,313 @@
+package client
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"strings"
+	"testing"
+
+	"9fans.net/go/plan9"
+)
+
+// newTestConn returns a Conn talking to a fake server with the given msize.
+// The server answers each T-message by calling serve, which returns
+// the R-message to send back; the tag is filled in automatically.
+// Replies are written by a separate goroutine: net.Pipe has no
+// buffering, and Conn may be busy writing a request when the
+// server has a reply ready, as happens with concurrent RPCs.
+func newTestConn(t testing.TB, msize uint32, serve func(tx *plan9.Fcall) *plan9.Fcall) *Conn {
+	c0, c1 := net.Pipe()
+	replies := make(chan *plan9.Fcall, 64)
+	go func() {
+		defer close(replies)
+		for {
+			tx, err := plan9.ReadFcall(c1)
+			if err != nil {
+				return
+			}
+			var rx *plan9.Fcall
+			if tx.Type == plan9.Tversion {
+				rx = &plan9.Fcall{Type: plan9.Rversion, Msize: msize, Version: tx.Version}
+			} else {
+				rx = serve(tx)
+			}
+			rx.Tag = tx.Tag
+			replies <- rx
+		}
+	}()
+	go func() {
+		defer c1.Close()
+		for rx := range replies {
+			if err := plan9.WriteFcall(c1, rx); err != nil {
+				return
+			}
+		}
+	}()
+	c, err := NewConn(c0)
+	if err != nil {
+		t.Fatalf("NewConn: %v", err)
+	}
+	return c
+}
+
+// dirServer returns a serve function for newTestConn that serves
+// dirs as the contents of every fid, packing as many whole entries
+// into each Rread as fit in the requested count.
+func dirServer(dirs []*plan9.Dir) func(tx *plan9.Fcall) *plan9.Fcall {
+	var data []byte
+	var offsets []uint64
+	for _, d := range dirs {
+		b, _ := d.Bytes()
+		offsets = append(offsets, uint64(len(data)))
+		data = append(data, b...)
+	}
+	offsets = append(offsets, uint64(len(data)))
+	return func(tx *plan9.Fcall) *plan9.Fcall {
+		if tx.Type != plan9.Tread {
+			return &plan9.Fcall{Type: plan9.Rerror, Ename: "unexpected message"}
+		}
+		start := -1
+		for i, o := range offsets {
+			if o == tx.Offset {
+				start = i
+			}
+		}
+		if start < 0 {
+			return &plan9.Fcall{Type: plan9.Rerror, Ename: "bad directory offset"}
+		}
+		end := start
+		for end+1 < len(offsets) && offsets[end+1]-tx.Offset <= uint64(tx.Count) {
+			end++
+		}
+		return &plan9.Fcall{Type: plan9.Rread, Data: data[offsets[start]:offsets[end]]}
+	}
+}
+
+func TestReadDir(t *testing.T) {
+	var dirs []*plan9.Dir
+	for i := 0; i < 50; i++ {
+		dirs = append(dirs, &plan9.Dir{Name: fmt.Sprintf("file%02d", i), Uid: "glenda", Gid: "glenda", Muid: "glenda"})
+	}
+	c := newTestConn(t, 256, dirServer(dirs))
+	defer c.Close()
+	fid := &Fid{c: c, fid: 1, qid: plan9.Qid{Type: plan9.QTDIR}}
+
+	var got []*plan9.Dir
+	for {
+		d, err := fid.ReadDir(7)
+		if err == io.EOF {
+			if len(d) != 0 {
+				t.Fatalf("ReadDir returned %d entries with io.EOF", len(d))
+			}
+			break
+		}
+		if err != nil {
+			t.Fatalf("ReadDir: %v", err)
+		}
+		if len(d) == 0 || len(d) > 7 {
+			t.Fatalf("ReadDir(7) returned %d entries", len(d))
+		}
+		got = append(got, d...)
+	}
+	if len(got) != len(dirs) {
+		t.Fatalf("read %d entries, want %d", len(got), len(dirs))
+	}
+	for i, d := range got {
+		if d.Name != dirs[i].Name {
+			t.Errorf("entry %d is %q, want %q", i, d.Name, dirs[i].Name)
+		}
+	}
+
+	// After any seek, entries buffered by ReadDir must not be returned.
+	if _, err := fid.Seek(0, 0); err != nil {
+		t.Fatalf("Seek: %v", err)
+	}
+	if _, err := fid.ReadDir(1); err != nil {
+		t.Fatalf("ReadDir after seek: %v", err)
+	}
+	if _, err := fid.Seek(0, 1); err != nil {
+		t.Fatalf("Seek: %v", err)
+	}
+	if d, err := fid.ReadDir(1); err != nil || len(d) != 1 || d[0].Name == dirs[1].Name {
+		t.Fatalf("ReadDir after Seek(0, 1) = %v, %v; returned stale buffered entry", d, err)
+	}
+	if _, err := fid.Seek(0, 0); err != nil {
+		t.Fatalf("Seek: %v", err)
+	}
+	if _, err := fid.ReadDir(3); err != nil {
+		t.Fatalf("ReadDir after seek: %v", err)
+	}
+	rest, err := fid.ReadDir(-1)
+	if err != nil {
+		t.Fatalf("ReadDir(-1): %v", err)
+	}
+	if len(rest) != len(dirs)-3 {
+		t.Fatalf("ReadDir(-1) returned %d entries, want %d", len(rest), len(dirs)-3)
+	}
+}
+
+func BenchmarkDirread(b *testing.B) {
+	var dirs []*plan9.Dir
+	for i := 0; i < 1000; i++ {
+		dirs = append(dirs, &plan9.Dir{Name: fmt.Sprintf("file%04d", i), Uid: "glenda", Gid: "glenda", Muid: "glenda"})
+	}
+	c := newTestConn(b, 8192, dirServer(dirs))
+	defer c.Close()
+	fid := &Fid{c: c, fid: 1, qid: plan9.Qid{Type: plan9.QTDIR}}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		fid.Seek(0, 0)
+		d, err := fid.Dirreadall()
+		if err != nil {
+			b.Fatal(err)
+		}
+		if len(d) != len(dirs) {
+			b.Fatalf("read %d entries, want %d", len(d), len(dirs))
+		}
+	}
+}
+
+func TestDirUnpackBoundary(t *testing.T) {
+	d := &plan9.Dir{Name: strings.Repeat("x", 255), Uid: "glenda", Gid: "glenda", Muid: "glenda"}
+	b, _ := d.Bytes()
+	dirs, err := dirUnpack(b)
+	if err != nil || len(dirs) != 1 || dirs[0].Name != d.Name {
+		t.Fatalf("dirUnpack of whole entry = %v, %v", dirs, err)
+	}
+	if _, err := dirUnpack(b[:len(b)-1]); err != io.ErrUnexpectedEOF {
+		t.Fatalf("dirUnpack of truncated entry: err = %v, want io.ErrUnexpectedEOF", err)
+	}
+}
+
+func TestIoUnit(t *testing.T) {
+	for _, msize := range []uint32{256, 8192, 131072} {
+		c := newTestConn(t, msize, nil)
+		if got, want := c.ioUnit(), msize-plan9.IOHDRSZ; got != want {
+			t.Errorf("msize %d: ioUnit() = %d, want %d", msize, got, want)
+		}
+		c.Close()
+	}
+
+	// An msize with no room for data past the I/O header is refused.
+	c0, c1 := net.Pipe()
+	go func() {
+		defer c1.Close()
+		tx, err := plan9.ReadFcall(c1)
+		if err != nil {
+			return
+		}
+		plan9.WriteFcall(c1, &plan9.Fcall{Type: plan9.Rversion, Tag: tx.Tag, Msize: 16, Version: tx.Version})
+	}()
+	if c, err := NewConn(c0); err == nil {
+		c.Close()
+		t.Errorf("NewConn accepted msize 16")
+	}
+	c0.Close()
+
+	const msize = 8192
+	for _, test := range []struct {
+		iounit uint32
+		want   uint32
+	}{
+		{0, msize - plan9.IOHDRSZ},
+		{100, 100},
+		{msize, msize - plan9.IOHDRSZ},
+	} {
+		var maxRead, maxWrite uint32
+		serve := func(tx *plan9.Fcall) *plan9.Fcall {
+			switch tx.Type {
+			case plan9.Topen:
+				return &plan9.Fcall{Type: plan9.Ropen, Iounit: test.iounit}
+			case plan9.Tread:
+				if tx.Count > maxRead {
+					maxRead = tx.Count
+				}
+				return &plan9.Fcall{Type: plan9.Rread, Data: make([]byte, tx.Count)}
+			case plan9.Twrite:
+				if n := uint32(len(tx.Data)); n > maxWrite {
+					maxWrite = n
+				}
+				return &plan9.Fcall{Type: plan9.Rwrite, Count: uint32(len(tx.Data))}
+			}
+			return &plan9.Fcall{Type: plan9.Rerror, Ename: "unexpected message"}
+		}
+		c := newTestConn(t, msize, serve)
+		fid := &Fid{c: c, fid: 1}
+		if err := fid.Open(plan9.ORDWR); err != nil {
+			t.Fatalf("Open: %v", err)
+		}
+		buf := make([]byte, 3*msize)
+		if _, err := fid.ReadAt(buf, 0); err != nil {
+			t.Fatalf("ReadAt: %v", err)
+		}
+		if _, err := fid.WriteAt(buf, 0); err != nil {
+			t.Fatalf("WriteAt: %v", err)
+		}
+		if maxRead != test.want || maxWrite != test.want {
+			t.Errorf("iounit %d: largest Tread count %d, Twrite size %d; want %d", test.iounit, maxRead, maxWrite, test.want)
+		}
+		c.Close()
+	}
+}
+
+func TestDirreadConcurrent(t *testing.T) {
+	var dirs []*plan9.Dir
+	for i := 0; i < 20; i++ {
+		dirs = append(dirs, &plan9.Dir{Name: fmt.Sprintf("file%02d", i)})
+	}
+	c := newTestConn(t, 8192, dirServer(dirs))
+	defer c.Close()
+	fid := &Fid{c: c, fid: 1, qid: plan9.Qid{Type: plan9.QTDIR}}
+
+	// The readers race on the fid offset, so the server may see
+	// offsets that are not entry boundaries and return errors.
+	// What matters is that the buffer is shared without a data race.
+	done := make(chan bool)
+	for i := 0; i < 4; i++ {
+		go func() {
+			for j := 0; j < 50; j++ {
+				fid.Dirread()
+			}
+			done <- true
+		}()
+	}
+	for i := 0; i < 4; i++ {
+		<-done
+	}
+}
+
+func BenchmarkReadAll(b *testing.B) {
+	data := make([]byte, 1<<20)
+	serve := func(tx *plan9.Fcall) *plan9.Fcall {
+		if tx.Type != plan9.Tread {
+			return &plan9.Fcall{Type: plan9.Rerror, Ename: "unexpected message"}
+		}
+		rx := &plan9.Fcall{Type: plan9.Rread}
+		if tx.Offset < uint64(len(data)) {
+			rx.Data = data[tx.Offset:]
+			if uint64(len(rx.Data)) > uint64(tx.Count) {
+				rx.Data = rx.Data[:tx.Count]
+			}
+		}
+		return rx
+	}
+	c := newTestConn(b, 8192, serve)
+	defer c.Close()
+	fid := &Fid{c: c, fid: 1}
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		fid.Seek(0, 0)
+		buf, err := ioutil.ReadAll(fid)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if len(buf) != len(data) {
+			b.Fatalf("read %d bytes, want %d", len(buf), len(data))
+		}
+	}
+}
@@ -1,6 +1,9 @@
 package client
 
 import (
+	"errors"
+	"fmt"
+	"os"
 	"strings"
 
 	"9fans.net/go/plan9"
@@ -86,6 +89,104 @@ func (fs *Fsys) Create(name string, mode uint8, perm plan9.Perm) (*Fid, error) {
 	return fid, nil
 }
 
+// CreateFile creates the file name with permissions perm,
+// writes data to it, and closes it.
+// If name already exists, CreateFile leaves it untouched and returns
+// an error satisfying errors.Is(err, os.ErrExist).
+// The existence check is not atomic with the create.
+// If another client creates name in between, CreateFile relies
+// on the server refusing the Tcreate, and still reports ErrExist.
+// If the data cannot be written, CreateFile removes the new file.
+func (fs *Fsys) CreateFile(name string, perm plan9.Perm, data []byte) error {
+	if err := fs.checkNotExist(name); err != nil {
+		return err
+	}
+	fid, err := fs.Create(name, plan9.OWRITE, perm)
+	if err != nil {
+		if eerr := fs.checkNotExist(name); eerr != nil && errors.Is(eerr, os.ErrExist) {
+			return eerr
+		}
+		return err
+	}
+	if _, err := fid.Write(data); err != nil {
+		fid.Remove()
+		return err
+	}
+	return fid.Close()
+}
+
+// checkNotExist returns nil if name does not exist,
+// an error wrapping os.ErrExist if it does,
+// and any other error if the walk failed for another reason.
+func (fs *Fsys) checkNotExist(name string) error {
+	fid, err := fs.root.Walk(name)
+	if err == nil {
+		fid.Close()
+		return fmt.Errorf("'%s' %w", name, os.ErrExist)
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// MkdirAll creates the directory name along with any missing parents.
+// Each directory created has permissions perm|DMDIR.
+// A parent created concurrently by another client is not an error.
+func (fs *Fsys) MkdirAll(name string, perm plan9.Perm) error {
+	fid, err := fs.root.Walk("")
+	if err != nil {
+		return err
+	}
+	var path string // elements walked so far, for errors
+	for _, elem := range strings.Split(name, "/") {
+		if elem == "" || elem == "." {
+			continue
+		}
+		if path != "" {
+			path += "/"
+		}
+		path += elem
+		nfid, err := fid.Walk(elem)
+		if errors.Is(err, os.ErrNotExist) {
+			nfid, err = fs.mkdir(fid, elem, perm)
+		}
+		fid.Close()
+		if err != nil {
+			return err
+		}
+		fid = nfid
+		if fid.qid.Type&plan9.QTDIR == 0 {
+			fid.Close()
+			return Error("'" + path + "' is not a directory")
+		}
+	}
+	return fid.Close()
+}
+
+// mkdir creates the directory elem in dir and returns
+// an unopened fid for it, walked from dir.
+// If the create fails because elem appeared in the meantime,
+// mkdir returns a fid for the existing elem instead.
+func (fs *Fsys) mkdir(dir *Fid, elem string, perm plan9.Perm) (*Fid, error) {
+	fid, err := dir.Walk("")
+	if err != nil {
+		return nil, err
+	}
+	// The created fid is open, and 9P does not allow walking
+	// from an open fid, so clunk it and walk afresh from dir.
+	err = fid.Create(elem, plan9.OREAD, perm|plan9.DMDIR)
+	fid.Close()
+	fid, werr := dir.Walk(elem)
+	if werr != nil {
+		if err == nil {
+			err = werr
+		}
+		return nil, err
+	}
+	return fid, nil
+}
+
 func (fs *Fsys) Open(name string, mode uint8) (*Fid, error) {
 	fid, err := fs.root.Walk(name)
 	if err != nil {
@@ -0,0 +1,325 @@
+package client
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"9fans.net/go/plan9"
+)
+
+// denyServer serves a root directory containing the single file
+// "secret", which cannot be opened.
+func denyServer(tx *plan9.Fcall) *plan9.Fcall {
+	switch tx.Type {
+	case plan9.Tattach:
+		return &plan9.Fcall{Type: plan9.Rattach, Qid: plan9.Qid{Type: plan9.QTDIR}}
+	case plan9.Twalk:
+		if len(tx.Wname) > 0 && tx.Wname[0] == "locked" {
+			return &plan9.Fcall{Type: plan9.Rerror, Ename: "permission denied"}
+		}
+		var wqid []plan9.Qid
+		for _, name := range tx.Wname {
+			if name != "secret" {
+				break
+			}
+			wqid = append(wqid, plan9.Qid{Path: 1})
+		}
+		if len(wqid) == 0 && len(tx.Wname) > 0 {
+			return &plan9.Fcall{Type: plan9.Rerror, Ename: "file does not exist"}
+		}
+		return &plan9.Fcall{Type: plan9.Rwalk, Wqid: wqid}
+	case plan9.Topen:
+		return &plan9.Fcall{Type: plan9.Rerror, Ename: "permission denied"}
+	case plan9.Tclunk:
+		return &plan9.Fcall{Type: plan9.Rclunk}
+	}
+	return &plan9.Fcall{Type: plan9.Rerror, Ename: "unexpected message"}
+}
+
+func TestOpenErrors(t *testing.T) {
+	c := newTestConn(t, 8192, denyServer)
+	defer c.Close()
+	fsys, err := c.Attach(nil, "glenda", "")
+	if err != nil {
+		t.Fatalf("Attach: %v", err)
+	}
+
+	_, err = fsys.Open("secret", plan9.OREAD)
+	if err == nil || err.Error() != "permission denied" {
+		t.Fatalf("Open(secret) = %v, want permission denied", err)
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Open(secret) error %v reports not exist", err)
+	}
+
+	for _, name := range []string{"missing", "secret/missing"} {
+		_, err = fsys.Open(name, plan9.OREAD)
+		if !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("Open(%s) = %v, want not exist", name, err)
+		}
+		if name == "secret/missing" && err.Error() != "'secret/missing' file does not exist" {
+			t.Errorf("Open(%s) = %q, want canonical not-exist message", name, err)
+		}
+		var werr *WalkError
+		if !errors.As(err, &werr) || werr.Name != name {
+			t.Errorf("Open(%s) = %#v, want *WalkError for %s", name, err, name)
+		}
+	}
+
+	_, err = fsys.Open("locked/file", plan9.OREAD)
+	if err == nil || errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Open(locked/file) = %v, want permission error that is not not-exist", err)
+	}
+
+	c.Close()
+	_, err = fsys.Open("missing", plan9.OREAD)
+	if err == nil || errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Open on closed connection = %v, want transport error that is not not-exist", err)
+	}
+}
+
+func TestCreateFile(t *testing.T) {
+	files := map[string][]byte{"old": []byte("old data")}
+	walked := make(map[uint32]string)
+	created := make(map[uint32]string)
+	var path uint64
+	var raceCreate, failWrite bool
+	serve := func(tx *plan9.Fcall) *plan9.Fcall {
+		switch tx.Type {
+		case plan9.Tattach:
+			return &plan9.Fcall{Type: plan9.Rattach, Qid: plan9.Qid{Type: plan9.QTDIR}}
+		case plan9.Twalk:
+			if len(tx.Wname) == 0 {
+				return &plan9.Fcall{Type: plan9.Rwalk}
+			}
+			if _, ok := files[tx.Wname[0]]; !ok || len(tx.Wname) > 1 {
+				return &plan9.Fcall{Type: plan9.Rerror, Ename: "file does not exist"}
+			}
+			walked[tx.Newfid] = tx.Wname[0]
+			return &plan9.Fcall{Type: plan9.Rwalk, Wqid: []plan9.Qid{{Path: 1}}}
+		case plan9.Tcreate:
+			if raceCreate {
+				// Another client creates the file first.
+				files[tx.Name] = []byte("theirs")
+			}
+			if _, ok := files[tx.Name]; ok {
+				return &plan9.Fcall{Type: plan9.Rerror, Ename: "file already exists"}
+			}
+			files[tx.Name] = nil
+			created[tx.Fid] = tx.Name
+			path++
+			return &plan9.Fcall{Type: plan9.Rcreate, Qid: plan9.Qid{Path: path}}
+		case plan9.Twrite:
+			name, ok := created[tx.Fid]
+			if !ok {
+				return &plan9.Fcall{Type: plan9.Rerror, Ename: "fid not open for write"}
+			}
+			if failWrite {
+				return &plan9.Fcall{Type: plan9.Rerror, Ename: "disk full"}
+			}
+			files[name] = append(files[name][:tx.Offset], tx.Data...)
+			return &plan9.Fcall{Type: plan9.Rwrite, Count: uint32(len(tx.Data))}
+		case plan9.Tclunk:
+			delete(walked, tx.Fid)
+			delete(created, tx.Fid)
+			return &plan9.Fcall{Type: plan9.Rclunk}
+		case plan9.Tremove:
+			if name, ok := created[tx.Fid]; ok {
+				delete(files, name)
+			}
+			delete(walked, tx.Fid)
+			delete(created, tx.Fid)
+			return &plan9.Fcall{Type: plan9.Rremove}
+		}
+		return &plan9.Fcall{Type: plan9.Rerror, Ename: "unexpected message"}
+	}
+	c := newTestConn(t, 8192, serve)
+	defer c.Close()
+	fsys, err := c.Attach(nil, "glenda", "")
+	if err != nil {
+		t.Fatalf("Attach: %v", err)
+	}
+
+	if err := fsys.CreateFile("new", 0644, []byte("hello, world")); err != nil {
+		t.Fatalf("CreateFile(new): %v", err)
+	}
+	if got := string(files["new"]); got != "hello, world" {
+		t.Errorf("new contains %q, want %q", got, "hello, world")
+	}
+
+	err = fsys.CreateFile("old", 0644, []byte("clobbered"))
+	if !errors.Is(err, os.ErrExist) {
+		t.Errorf("CreateFile(old) = %v, want exist error", err)
+	}
+	if got := string(files["old"]); got != "old data" {
+		t.Errorf("old contains %q after failed CreateFile", got)
+	}
+
+	raceCreate = true
+	err = fsys.CreateFile("racy", 0644, []byte("mine"))
+	raceCreate = false
+	if !errors.Is(err, os.ErrExist) {
+		t.Errorf("CreateFile(racy) = %v, want exist error", err)
+	}
+	if got := string(files["racy"]); got != "theirs" {
+		t.Errorf("racy contains %q after lost race", got)
+	}
+
+	failWrite = true
+	err = fsys.CreateFile("partial", 0644, []byte("data"))
+	failWrite = false
+	if err == nil {
+		t.Errorf("CreateFile(partial) succeeded despite write failure")
+	}
+	if _, ok := files["partial"]; ok {
+		t.Errorf("partial left behind after write failure")
+	}
+
+	if len(walked)+len(created) != 0 {
+		t.Errorf("fids left open: walked %v, created %v", walked, created)
+	}
+
+	c.Close()
+	err = fsys.CreateFile("dead", 0644, nil)
+	if err == nil || errors.Is(err, os.ErrExist) {
+		t.Errorf("CreateFile on closed connection = %v, want transport error", err)
+	}
+	if _, ok := files["dead"]; ok {
+		t.Errorf("CreateFile on closed connection created a file")
+	}
+}
+
+// A treeServer is a fake file server holding a tree of empty
+// files and directories. Like a real 9P server, it refuses
+// to walk from or create in a fid that is already open.
+type treeServer struct {
+	dirs map[string]bool // path => is directory; the root is ""
+	fids map[uint32]*treeFid
+
+	// beforeCreate, if set, is called before each Tcreate
+	// with the path about to be created.
+	beforeCreate func(path string)
+}
+
+type treeFid struct {
+	path string
+	open bool
+}
+
+func newTreeServer() *treeServer {
+	return &treeServer{
+		dirs: map[string]bool{"": true},
+		fids: make(map[uint32]*treeFid),
+	}
+}
+
+func treeJoin(dir, name string) string {
+	if dir == "" {
+		return name
+	}
+	return dir + "/" + name
+}
+
+func (s *treeServer) qid(path string) plan9.Qid {
+	if s.dirs[path] {
+		return plan9.Qid{Type: plan9.QTDIR}
+	}
+	return plan9.Qid{}
+}
+
+func (s *treeServer) serve(tx *plan9.Fcall) *plan9.Fcall {
+	if tx.Type == plan9.Tattach {
+		s.fids[tx.Fid] = &treeFid{}
+		return &plan9.Fcall{Type: plan9.Rattach, Qid: s.qid("")}
+	}
+	f := s.fids[tx.Fid]
+	if f == nil {
+		return &plan9.Fcall{Type: plan9.Rerror, Ename: "unknown fid"}
+	}
+	switch tx.Type {
+	case plan9.Twalk:
+		if f.open {
+			return &plan9.Fcall{Type: plan9.Rerror, Ename: "walk of open fid"}
+		}
+		path := f.path
+		var wqid []plan9.Qid
+		for _, name := range tx.Wname {
+			if _, ok := s.dirs[treeJoin(path, name)]; !ok || !s.dirs[path] {
+				break
+			}
+			path = treeJoin(path, name)
+			wqid = append(wqid, s.qid(path))
+		}
+		if len(wqid) == 0 && len(tx.Wname) > 0 {
+			return &plan9.Fcall{Type: plan9.Rerror, Ename: "file does not exist"}
+		}
+		if len(wqid) == len(tx.Wname) {
+			s.fids[tx.Newfid] = &treeFid{path: path}
+		}
+		return &plan9.Fcall{Type: plan9.Rwalk, Wqid: wqid}
+	case plan9.Tcreate:
+		if f.open || !s.dirs[f.path] {
+			return &plan9.Fcall{Type: plan9.Rerror, Ename: "create in non-directory"}
+		}
+		path := treeJoin(f.path, tx.Name)
+		if s.beforeCreate != nil {
+			s.beforeCreate(path)
+		}
+		if _, ok := s.dirs[path]; ok {
+			return &plan9.Fcall{Type: plan9.Rerror, Ename: "file already exists"}
+		}
+		s.dirs[path] = tx.Perm&plan9.DMDIR != 0
+		f.path = path
+		f.open = true
+		return &plan9.Fcall{Type: plan9.Rcreate, Qid: s.qid(path)}
+	case plan9.Tclunk:
+		delete(s.fids, tx.Fid)
+		return &plan9.Fcall{Type: plan9.Rclunk}
+	}
+	return &plan9.Fcall{Type: plan9.Rerror, Ename: "unexpected message"}
+}
+
+func TestMkdirAll(t *testing.T) {
+	s := newTreeServer()
+	c := newTestConn(t, 8192, s.serve)
+	defer c.Close()
+	fsys, err := c.Attach(nil, "glenda", "")
+	if err != nil {
+		t.Fatalf("Attach: %v", err)
+	}
+
+	if err := fsys.MkdirAll("/a/b/c", 0755); err != nil {
+		t.Fatalf("MkdirAll(/a/b/c): %v", err)
+	}
+	for _, path := range []string{"a", "a/b", "a/b/c"} {
+		if !s.dirs[path] {
+			t.Errorf("%s was not created as a directory", path)
+		}
+	}
+	if err := fsys.MkdirAll("a/b", 0755); err != nil {
+		t.Errorf("MkdirAll of existing a/b: %v", err)
+	}
+
+	// Another client creates a/x/y between our walk and our create.
+	s.beforeCreate = func(path string) {
+		if path == "a/x/y" {
+			s.dirs[path] = true
+		}
+	}
+	if err := fsys.MkdirAll("a/x/y/z", 0755); err != nil {
+		t.Fatalf("MkdirAll(a/x/y/z) racing another client: %v", err)
+	}
+	if !s.dirs["a/x/y/z"] {
+		t.Errorf("a/x/y/z was not created as a directory")
+	}
+
+	s.dirs["a/file"] = false
+	err = fsys.MkdirAll("a/file/d", 0755)
+	if err == nil || err.Error() != "'a/file' is not a directory" {
+		t.Errorf("MkdirAll through a regular file = %v, want 'a/file' is not a directory", err)
+	}
+	if len(s.fids) != 1 {
+		t.Errorf("%d fids in use after MkdirAll, want 1 (the root)", len(s.fids))
+	}
+}
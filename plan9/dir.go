@@ -12,7 +12,8 @@ func (e ProtocolError) Error() string {
 }
 
 const (
-	STATMAX = 65535
+	STATMAX    = 65535
+	STATFIXLEN = 49 // length of a marshaled Dir with empty strings, including size prefix
 )
 
 type Dir struct {
@@ -47,6 +48,75 @@ func (d *Dir) Null() {
 	*d = nullDir
 }
 
+// DirChanges records which fields of a Dir a Twstat changes.
+type DirChanges struct {
+	Name   bool
+	Length bool
+	Mode   bool
+	Mtime  bool
+	Gid    bool
+}
+
+// ApplyWstat applies the Twstat request req to cur, the current
+// state of the file, and reports which fields changed.
+// A field in req is left alone when it holds the "don't touch" value
+// that Dir.Null sets: all ones for numbers, the empty string for strings.
+// As in the protocol, only the name, length, mode, mtime and gid
+// can change. Setting type, dev, qid, atime, uid or muid is an error
+// unless the new value equals the current one. It is also an error
+// to toggle DMDIR, to set a directory's length to a non-zero value,
+// or to rename the file to ".", "..", or a name that is not a valid
+// file name element.
+// On error, cur is unchanged.
+func ApplyWstat(cur *Dir, req *Dir) (DirChanges, error) {
+	var c DirChanges
+	if req.Type != nullDir.Type && req.Type != cur.Type ||
+		req.Dev != nullDir.Dev && req.Dev != cur.Dev ||
+		req.Qid != nullDir.Qid && req.Qid != cur.Qid ||
+		req.Atime != nullDir.Atime && req.Atime != cur.Atime ||
+		req.Uid != "" && req.Uid != cur.Uid ||
+		req.Muid != "" && req.Muid != cur.Muid {
+		return DirChanges{}, ProtocolError("wstat: cannot change type, dev, qid, atime, uid or muid")
+	}
+	d := *cur
+	if req.Name != "" && req.Name != cur.Name {
+		if req.Name == "." || req.Name == ".." {
+			return DirChanges{}, ProtocolError(fmt.Sprintf("wstat: cannot rename to %q", req.Name))
+		}
+		if err := checkName(req.Name); err != nil {
+			return DirChanges{}, err
+		}
+		d.Name = req.Name
+		c.Name = true
+	}
+	if req.Length != nullDir.Length {
+		if cur.Mode&DMDIR != 0 && req.Length != 0 {
+			return DirChanges{}, ProtocolError("wstat: cannot set non-zero length of directory")
+		}
+		if req.Length != cur.Length {
+			d.Length = req.Length
+			c.Length = true
+		}
+	}
+	if req.Mode != nullDir.Mode && req.Mode != cur.Mode {
+		if (req.Mode^cur.Mode)&DMDIR != 0 {
+			return DirChanges{}, ProtocolError("wstat: cannot change DMDIR bit")
+		}
+		d.Mode = req.Mode
+		c.Mode = true
+	}
+	if req.Mtime != nullDir.Mtime && req.Mtime != cur.Mtime {
+		d.Mtime = req.Mtime
+		c.Mtime = true
+	}
+	if req.Gid != "" && req.Gid != cur.Gid {
+		d.Gid = req.Gid
+		c.Gid = true
+	}
+	*cur = d
+	return c, nil
+}
+
 func pdir(b []byte, d *Dir) []byte {
 	n := len(b)
 	b = pbit16(b, 0) // length, filled in later
@@ -65,7 +135,18 @@ func pdir(b []byte, d *Dir) []byte {
 	return b
 }
 
+// MarshalSize returns the number of bytes in the marshaled form of d,
+// including the two-byte size prefix; that is, len(d.Bytes()).
+func (d *Dir) MarshalSize() int {
+	return STATFIXLEN + len(d.Name) + len(d.Uid) + len(d.Gid) + len(d.Muid)
+}
+
 func (d *Dir) Bytes() ([]byte, error) {
+	// The size prefix is 16 bits; anything larger cannot be represented
+	// and would otherwise be silently truncated (or panic in pstring).
+	if d.MarshalSize()-2 > STATMAX {
+		return nil, ProtocolError("stat too long")
+	}
 	return pdir(nil, d), nil
 }
 
@@ -0,0 +1,136 @@
+package plan9
+
+import (
+	"strings"
+	"testing"
+)
+
+var marshalSizeTests = []Dir{
+	{},
+	{Name: "a"},
+	{Name: "lib", Uid: "glenda", Gid: "sys", Muid: "glenda", Mode: DMDIR | 0775, Qid: Qid{Path: 1, Type: QTDIR}},
+	{Name: strings.Repeat("x", 255), Uid: "u", Gid: "g", Muid: "m", Length: 1 << 40},
+}
+
+func TestMarshalSize(t *testing.T) {
+	for _, d := range marshalSizeTests {
+		b, err := d.Bytes()
+		if err != nil {
+			t.Errorf("%v: Bytes: %v", &d, err)
+			continue
+		}
+		if n := d.MarshalSize(); n != len(b) {
+			t.Errorf("%v: MarshalSize() = %d, len(Bytes()) = %d", &d, n, len(b))
+		}
+	}
+}
+
+func TestDirLongNames(t *testing.T) {
+	long := strings.Repeat("x", 255)
+	d := &Dir{Name: long, Uid: long, Gid: long, Muid: long}
+	b, err := d.Bytes()
+	if err != nil {
+		t.Fatalf("Bytes: %v", err)
+	}
+	d1, err := UnmarshalDir(b)
+	if err != nil {
+		t.Fatalf("UnmarshalDir: %v", err)
+	}
+	if *d1 != *d {
+		t.Fatalf("round trip mismatch:\n%v\n%v", d, d1)
+	}
+
+	// Largest Dir whose size still fits in the 16-bit prefix.
+	d = &Dir{Name: strings.Repeat("x", STATMAX-(STATFIXLEN-2))}
+	if _, err := d.Bytes(); err != nil {
+		t.Fatalf("Bytes of maximal Dir: %v", err)
+	}
+	d.Uid = "u"
+	if _, err := d.Bytes(); err == nil {
+		t.Fatalf("Bytes of oversized Dir succeeded")
+	}
+	d = &Dir{Name: strings.Repeat("x", 1<<16)}
+	if _, err := d.Bytes(); err == nil {
+		t.Fatalf("Bytes of Dir with oversized name succeeded")
+	}
+}
+
+type wstatTest struct {
+	name    string
+	req     func(d *Dir)
+	changes DirChanges
+	err     bool
+}
+
+var wstatTests = []wstatTest{
+	{"no-op", func(d *Dir) {}, DirChanges{}, false},
+	{"rename", func(d *Dir) { d.Name = "new" }, DirChanges{Name: true}, false},
+	{"chmod", func(d *Dir) { d.Mode = 0600 }, DirChanges{Mode: true}, false},
+	{"truncate", func(d *Dir) { d.Length = 0 }, DirChanges{Length: true}, false},
+	{"mtime and gid", func(d *Dir) { d.Mtime = 99; d.Gid = "sys" }, DirChanges{Mtime: true, Gid: true}, false},
+	{"same name", func(d *Dir) { d.Name = "old" }, DirChanges{}, false},
+	{"same qid", func(d *Dir) { d.Qid = Qid{Path: 1} }, DirChanges{}, false},
+	{"change qid", func(d *Dir) { d.Qid = Qid{Path: 2} }, DirChanges{}, true},
+	{"change uid", func(d *Dir) { d.Uid = "bootes" }, DirChanges{}, true},
+	{"change atime", func(d *Dir) { d.Atime = 1 }, DirChanges{}, true},
+	{"set DMDIR", func(d *Dir) { d.Mode = DMDIR | 0755 }, DirChanges{}, true},
+	{"rename with slash", func(d *Dir) { d.Name = "a/b" }, DirChanges{}, true},
+	{"rename to dot", func(d *Dir) { d.Name = "." }, DirChanges{}, true},
+	{"rename to dotdot", func(d *Dir) { d.Name = ".." }, DirChanges{}, true},
+}
+
+func TestApplyWstat(t *testing.T) {
+	for _, test := range wstatTests {
+		cur := Dir{Qid: Qid{Path: 1}, Mode: 0644, Mtime: 10, Length: 100, Name: "old", Uid: "glenda", Gid: "glenda", Muid: "glenda"}
+		orig := cur
+		var req Dir
+		req.Null()
+		test.req(&req)
+		c, err := ApplyWstat(&cur, &req)
+		if test.err {
+			if err == nil {
+				t.Errorf("%s: ApplyWstat succeeded, want error", test.name)
+			}
+			if cur != orig {
+				t.Errorf("%s: ApplyWstat changed cur on error: %v", test.name, &cur)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: ApplyWstat: %v", test.name, err)
+			continue
+		}
+		if c != test.changes {
+			t.Errorf("%s: changes = %+v, want %+v", test.name, c, test.changes)
+		}
+		if test.changes.Name && cur.Name != req.Name ||
+			test.changes.Mode && cur.Mode != req.Mode ||
+			test.changes.Length && cur.Length != req.Length {
+			t.Errorf("%s: cur = %v after applying %v", test.name, &cur, &req)
+		}
+		if c == (DirChanges{}) && cur != orig {
+			t.Errorf("%s: no-op wstat changed cur: %v", test.name, &cur)
+		}
+	}
+
+	// Some servers report a non-zero length for directories.
+	// Setting it to zero is allowed; any non-zero length is not,
+	// even the current one.
+	for _, length := range []uint64{10, 20} {
+		dir := Dir{Qid: Qid{Type: QTDIR}, Mode: DMDIR | 0755, Name: "d", Length: 20}
+		var req Dir
+		req.Null()
+		req.Length = length
+		if _, err := ApplyWstat(&dir, &req); err == nil {
+			t.Errorf("ApplyWstat set directory length to %d", length)
+		}
+	}
+	dir := Dir{Qid: Qid{Type: QTDIR}, Mode: DMDIR | 0755, Name: "d", Length: 20}
+	var req Dir
+	req.Null()
+	req.Length = 0
+	c, err := ApplyWstat(&dir, &req)
+	if err != nil || !c.Length || dir.Length != 0 {
+		t.Errorf("ApplyWstat truncating directory = %+v, %v; length now %d", c, err, dir.Length)
+	}
+}
@@ -0,0 +1,147 @@
+package plan9
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ValidateFcall checks that f obeys the 9P2000 rules for its message type
+// that can be checked without reference to other messages:
+// the type is known, Tversion and Rversion use NOTAG and nothing else does,
+// fids that must name a file are not NOFID, walks carry at most MAXWELEM
+// elements, file names are well formed, and variable-length fields fit
+// in their size prefixes.
+// It does not check rules that span messages, such as an Rwalk having
+// no more qids than its Twalk had names.
+func ValidateFcall(f *Fcall) error {
+	if f.Type < Tversion || f.Type >= Tmax || f.Type == Terror {
+		return ProtocolError(fmt.Sprintf("invalid message type %d", f.Type))
+	}
+	if f.Type == Tversion || f.Type == Rversion {
+		if f.Tag != NOTAG {
+			return ProtocolError(fmt.Sprintf("tag %d in %s; must be NOTAG", f.Tag, typeName(f.Type)))
+		}
+	} else if f.Tag == NOTAG {
+		return ProtocolError(fmt.Sprintf("NOTAG in %s", typeName(f.Type)))
+	}
+
+	switch f.Type {
+	case Tversion, Rversion:
+		if err := checkString("version", f.Version); err != nil {
+			return err
+		}
+
+	case Tauth:
+		if f.Afid == NOFID {
+			return ProtocolError("NOFID afid in Tauth")
+		}
+		return checkStrings(f.Uname, f.Aname)
+
+	case Tattach:
+		if f.Fid == NOFID {
+			return ProtocolError("NOFID fid in Tattach")
+		}
+		return checkStrings(f.Uname, f.Aname)
+
+	case Rerror:
+		return checkString("ename", f.Ename)
+
+	case Twalk:
+		if f.Fid == NOFID || f.Newfid == NOFID {
+			return ProtocolError("NOFID in Twalk")
+		}
+		if len(f.Wname) > MAXWELEM {
+			return ProtocolError(fmt.Sprintf("%d names in Twalk; max %d", len(f.Wname), MAXWELEM))
+		}
+		for _, name := range f.Wname {
+			if err := checkName(name); err != nil {
+				return err
+			}
+		}
+
+	case Rwalk:
+		if len(f.Wqid) > MAXWELEM {
+			return ProtocolError(fmt.Sprintf("%d qids in Rwalk; max %d", len(f.Wqid), MAXWELEM))
+		}
+
+	case Topen, Tread, Twrite, Tclunk, Tremove, Tstat, Twstat:
+		if f.Fid == NOFID {
+			return ProtocolError(fmt.Sprintf("NOFID fid in %s", typeName(f.Type)))
+		}
+
+	case Tcreate:
+		if f.Fid == NOFID {
+			return ProtocolError("NOFID fid in Tcreate")
+		}
+		if f.Name == "." || f.Name == ".." {
+			return ProtocolError(fmt.Sprintf("cannot create %q", f.Name))
+		}
+		return checkName(f.Name)
+	}
+
+	switch f.Type {
+	case Twrite, Rread:
+		if uint64(len(f.Data)) > 1<<32-1 {
+			return ProtocolError(fmt.Sprintf("data too long in %s", typeName(f.Type)))
+		}
+	case Twstat, Rstat:
+		if len(f.Stat) > STATMAX {
+			return ProtocolError(fmt.Sprintf("stat too long in %s", typeName(f.Type)))
+		}
+	}
+	return nil
+}
+
+func checkStrings(s ...string) error {
+	for _, s := range s {
+		if err := checkString("string", s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkString(what, s string) error {
+	if len(s) >= 1<<16 {
+		return ProtocolError(what + " too long")
+	}
+	return nil
+}
+
+// checkName checks that name is a valid file name element.
+func checkName(name string) error {
+	if name == "" {
+		return ProtocolError("empty file name")
+	}
+	if strings.IndexByte(name, '/') >= 0 {
+		return ProtocolError(fmt.Sprintf("file name %q contains a slash", name))
+	}
+	if strings.IndexByte(name, 0) >= 0 {
+		return ProtocolError(fmt.Sprintf("file name %q contains a NUL byte", name))
+	}
+	return checkString("file name", name)
+}
+
+func typeName(t uint8) string {
+	if t < Tversion || t >= Tmax {
+		return fmt.Sprintf("type %d", t)
+	}
+	return typeNames[t-Tversion]
+}
+
+var typeNames = [Tmax - Tversion]string{
+	"Tversion", "Rversion",
+	"Tauth", "Rauth",
+	"Tattach", "Rattach",
+	"Terror", "Rerror",
+	"Tflush", "Rflush",
+	"Twalk", "Rwalk",
+	"Topen", "Ropen",
+	"Tcreate", "Rcreate",
+	"Tread", "Rread",
+	"Twrite", "Rwrite",
+	"Tclunk", "Rclunk",
+	"Tremove", "Rremove",
+	"Tstat", "Rstat",
+	"Twstat", "Rwstat",
+}
@@ -0,0 +1,102 @@
+package plan9
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var validFcalls = []*Fcall{
+	{Type: Tversion, Tag: NOTAG, Msize: 8192, Version: VERSION9P},
+	{Type: Rversion, Tag: NOTAG, Msize: 8192, Version: VERSION9P},
+	{Type: Tauth, Tag: 1, Afid: 1, Uname: "glenda", Aname: ""},
+	{Type: Tattach, Tag: 1, Fid: 1, Afid: NOFID, Uname: "glenda", Aname: ""},
+	{Type: Rattach, Tag: 1, Qid: Qid{Type: QTDIR}},
+	{Type: Rerror, Tag: 1, Ename: "file does not exist"},
+	{Type: Tflush, Tag: 2, Oldtag: 1},
+	{Type: Twalk, Tag: 1, Fid: 1, Newfid: 2, Wname: []string{}},
+	{Type: Twalk, Tag: 1, Fid: 1, Newfid: 2, Wname: []string{"..", "usr", "glenda"}},
+	{Type: Twalk, Tag: 1, Fid: 1, Newfid: 2, Wname: maxWnames("a")},
+	{Type: Rwalk, Tag: 1, Wqid: []Qid{{Path: 1}, {Path: 2}}},
+	{Type: Topen, Tag: 1, Fid: 1, Mode: OREAD},
+	{Type: Tcreate, Tag: 1, Fid: 1, Name: "new", Perm: 0666, Mode: ORDWR},
+	{Type: Tread, Tag: 1, Fid: 1, Offset: 10, Count: 100},
+	{Type: Rread, Tag: 1, Data: []byte("hello")},
+	{Type: Twrite, Tag: 1, Fid: 1, Offset: 10, Data: []byte("hello")},
+	{Type: Rwrite, Tag: 1, Count: 5},
+	{Type: Tclunk, Tag: 1, Fid: 1},
+	{Type: Tstat, Tag: 1, Fid: 1},
+}
+
+func maxWnames(name string) []string {
+	names := make([]string, MAXWELEM)
+	for i := range names {
+		names[i] = name
+	}
+	return names
+}
+
+func TestValidateFcall(t *testing.T) {
+	for _, f := range validFcalls {
+		if err := ValidateFcall(f); err != nil {
+			t.Errorf("ValidateFcall(%v): %v", f, err)
+			continue
+		}
+		b, err := f.Bytes()
+		if err != nil {
+			t.Errorf("%v: Bytes: %v", f, err)
+			continue
+		}
+		f1, err := UnmarshalFcall(b)
+		if err != nil {
+			t.Errorf("%v: UnmarshalFcall: %v", f, err)
+			continue
+		}
+		if err := ValidateFcall(f1); err != nil {
+			t.Errorf("ValidateFcall(%v) after round trip: %v", f1, err)
+		}
+		if !reflect.DeepEqual(f.Wname, f1.Wname) || f.Type != f1.Type || f.Tag != f1.Tag {
+			t.Errorf("round trip mismatch:\n%v\n%v", f, f1)
+		}
+	}
+}
+
+type badFcallTest struct {
+	f   *Fcall
+	err string
+}
+
+var badFcalls = []badFcallTest{
+	{&Fcall{Type: Terror, Tag: 1}, "invalid message type"},
+	{&Fcall{Type: Tmax, Tag: 1}, "invalid message type"},
+	{&Fcall{Type: 0, Tag: 1}, "invalid message type"},
+	{&Fcall{Type: Tversion, Tag: 1, Version: VERSION9P}, "must be NOTAG"},
+	{&Fcall{Type: Rversion, Tag: 0, Version: VERSION9P}, "must be NOTAG"},
+	{&Fcall{Type: Tclunk, Tag: NOTAG, Fid: 1}, "NOTAG in Tclunk"},
+	{&Fcall{Type: Tauth, Tag: 1, Afid: NOFID}, "NOFID afid"},
+	{&Fcall{Type: Tattach, Tag: 1, Fid: NOFID, Afid: NOFID}, "NOFID fid"},
+	{&Fcall{Type: Twalk, Tag: 1, Fid: 1, Newfid: NOFID}, "NOFID in Twalk"},
+	{&Fcall{Type: Twalk, Tag: 1, Fid: 1, Newfid: 2, Wname: append(maxWnames("a"), "b")}, "names in Twalk"},
+	{&Fcall{Type: Twalk, Tag: 1, Fid: 1, Newfid: 2, Wname: []string{"a/b"}}, "contains a slash"},
+	{&Fcall{Type: Twalk, Tag: 1, Fid: 1, Newfid: 2, Wname: []string{""}}, "empty file name"},
+	{&Fcall{Type: Twalk, Tag: 1, Fid: 1, Newfid: 2, Wname: []string{"a\x00"}}, "NUL byte"},
+	{&Fcall{Type: Rwalk, Tag: 1, Wqid: make([]Qid, MAXWELEM+1)}, "qids in Rwalk"},
+	{&Fcall{Type: Topen, Tag: 1, Fid: NOFID}, "NOFID fid in Topen"},
+	{&Fcall{Type: Tcreate, Tag: 1, Fid: 1, Name: ".."}, "cannot create"},
+	{&Fcall{Type: Tcreate, Tag: 1, Fid: 1, Name: strings.Repeat("x", 1<<16)}, "too long"},
+	{&Fcall{Type: Rerror, Tag: 1, Ename: strings.Repeat("x", 1<<16)}, "ename too long"},
+	{&Fcall{Type: Rstat, Tag: 1, Stat: make([]byte, STATMAX+1)}, "stat too long"},
+}
+
+func TestValidateFcallErrors(t *testing.T) {
+	for _, test := range badFcalls {
+		err := ValidateFcall(test.f)
+		if err == nil {
+			t.Errorf("ValidateFcall(%v) succeeded; want error containing %q", test.f, test.err)
+			continue
+		}
+		if !strings.Contains(err.Error(), test.err) {
+			t.Errorf("ValidateFcall(%v) = %q; want error containing %q", test.f, err, test.err)
+		}
+	}
+}